@@ -14,19 +14,6 @@ import (
 
 const defaultAuthPluginName = mysql.AUTH_NATIVE_PASSWORD
 
-// defines the supported auth plugins
-var supportedAuthPlugins = []string{mysql.AUTH_NATIVE_PASSWORD, mysql.AUTH_SHA256_PASSWORD, mysql.AUTH_CACHING_SHA2_PASSWORD, mysql.AUTH_MARIADB_ED25519}
-
-// helper function to determine what auth methods are allowed by this client
-func authPluginAllowed(pluginName string) bool {
-	for _, p := range supportedAuthPlugins {
-		if pluginName == p {
-			return true
-		}
-	}
-	return false
-}
-
 // See:
 //   - https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_handshake_v10.html
 //   - https://github.com/alibaba/canal/blob/0ec46991499a22870dde4ae736b2586cbcbfea94/driver/src/main/java/com/alibaba/otter/canal/parse/driver/mysql/packets/server/HandshakeInitializationPacket.java#L89
@@ -151,40 +138,27 @@ func (c *Conn) readInitialHandshake() error {
 // here the \NUL needs to be added when sending back the empty password or cleartext password in 'sha256_password'
 // authentication.
 func (c *Conn) genAuthResponse(authData []byte) ([]byte, bool, error) {
-	// password hashing
+	plugin, ok := getAuthPlugin(c.authPluginName)
+	if !ok {
+		// not reachable in practice, writeAuthHandshake and runAuthHandshake
+		// already look the plugin up with getAuthPlugin
+		return nil, false, fmt.Errorf("auth plugin '%s' is not supported", c.authPluginName)
+	}
+
+	data, err := plugin.InitialResponse(authData, c)
+	if err != nil {
+		return nil, false, err
+	}
+
+	addNull := false
 	switch c.authPluginName {
-	case mysql.AUTH_NATIVE_PASSWORD:
-		return mysql.CalcPassword(authData[:20], []byte(c.password)), false, nil
-	case mysql.AUTH_CACHING_SHA2_PASSWORD:
-		return mysql.CalcCachingSha2Password(authData, c.password), false, nil
 	case mysql.AUTH_CLEAR_PASSWORD:
-		return []byte(c.password), true, nil
+		addNull = true
 	case mysql.AUTH_SHA256_PASSWORD:
-		if len(c.password) == 0 {
-			return nil, true, nil
-		}
-		if c.tlsConfig != nil || c.proto == "unix" {
-			// write cleartext auth packet
-			// see: https://dev.mysql.com/doc/refman/8.0/en/sha256-pluggable-authentication.html
-			return []byte(c.password), true, nil
-		} else {
-			// request public key from server
-			// see: https://dev.mysql.com/doc/internals/en/public-key-retrieval.html
-			return []byte{1}, false, nil
-		}
-	case mysql.AUTH_MARIADB_ED25519:
-		if len(authData) != 32 {
-			return nil, false, mysql.ErrMalformPacket
-		}
-		res, err := mysql.CalcEd25519Password(authData, c.password)
-		if err != nil {
-			return nil, false, err
-		}
-		return res, false, nil
-	default:
-		// not reachable
-		return nil, false, fmt.Errorf("auth plugin '%s' is not supported", c.authPluginName)
+		addNull = len(c.password) == 0 || c.tlsConfig != nil || c.proto == "unix"
 	}
+
+	return data, addNull, nil
 }
 
 // generate connection attributes data
@@ -203,9 +177,18 @@ func (c *Conn) genAttributes() []byte {
 
 // See: http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeResponse
 func (c *Conn) writeAuthHandshake() error {
-	if !authPluginAllowed(c.authPluginName) {
+	plugin, ok := getAuthPlugin(c.authPluginName)
+	if !ok {
 		return fmt.Errorf("unknown auth plugin name '%s'", c.authPluginName)
 	}
+	// a malicious or misconfigured server could otherwise name
+	// mysql_clear_password (or another plugin requiring TLS/a unix
+	// socket) as its default plugin in the initial handshake and read the
+	// password back in the clear - the same downgrade protection
+	// runAuthHandshake already applies on AuthSwitchRequest.
+	if plugin.NeedsTLS() && c.tlsConfig == nil && c.proto != "unix" {
+		return fmt.Errorf("auth plugin '%s' requires TLS or a unix socket, refusing to use it", c.authPluginName)
+	}
 
 	// Set default client capabilities that reflect the abilities of this library
 	capability := mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_SECURE_CONNECTION |
@@ -0,0 +1,172 @@
+package client
+
+import (
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// AuthPlugin is implemented by a MySQL/MariaDB authentication method.
+//
+// The connection phase drives a plugin in two steps: InitialResponse is
+// called once, with the salt taken from the initial handshake packet (or
+// from an AuthSwitchRequest), to build the auth-response bytes sent in the
+// handshake response packet. If the server follows up with a "more data"
+// packet for a plugin other than sha256_password or caching_sha2_password,
+// runAuthHandshake (shared by the initial Connect and ChangeUser, see
+// continueAuth in pubkey.go) calls Next with each such packet's
+// plugin-specific payload - with the generic 0x01 "more data" marker
+// already stripped - and writes any non-nil response back; the exchange
+// ends when the server sends its OK or ERR packet, whatever done Next
+// reported. Built-ins that need a round trip (sha256_password,
+// caching_sha2_password) are driven directly by continueAuth instead, so
+// they can share the pinned/cached RSA key with InitialResponse; their Next
+// is never called and is a no-op.
+//
+// runAuthHandshake also refuses to switch to a plugin whose NeedsTLS is
+// true outside of TLS/a unix socket, so a malicious or misconfigured server
+// can't downgrade auth to mysql_clear_password over a plain socket.
+//
+// Built-in plugins (mysql_native_password, sha256_password,
+// caching_sha2_password, mysql_clear_password, client_ed25519) are
+// registered by this package's init(). Callers can add their own
+// (Kerberos/GSSAPI, PAM dialog, AWS RDS IAM, a PKCS#11-backed signer, ...)
+// with RegisterAuthPlugin before dialing.
+type AuthPlugin interface {
+	// Name is the plugin name as advertised on the wire, e.g.
+	// "caching_sha2_password".
+	Name() string
+
+	// InitialResponse computes the auth-response bytes to send for this
+	// plugin, given the server's scramble/salt and the connection being
+	// authenticated.
+	InitialResponse(salt []byte, conn *Conn) ([]byte, error)
+
+	// Next computes the response to an additional auth-method-data
+	// packet. done is true once the plugin has nothing further to send
+	// and is waiting on the server's final OK/ERR packet.
+	Next(serverData []byte) (resp []byte, done bool, err error)
+
+	// NeedsTLS reports whether this plugin is only safe to use over an
+	// encrypted channel or a unix socket, e.g. mysql_clear_password.
+	NeedsTLS() bool
+}
+
+var authPluginRegistry = map[string]AuthPlugin{}
+
+// RegisterAuthPlugin adds p to the set of auth plugins this client can
+// negotiate, keyed by p.Name(). Registering a name that is already present
+// replaces the previous plugin, so callers may override a built-in.
+func RegisterAuthPlugin(p AuthPlugin) {
+	authPluginRegistry[p.Name()] = p
+}
+
+func getAuthPlugin(name string) (AuthPlugin, bool) {
+	p, ok := authPluginRegistry[name]
+	return p, ok
+}
+
+func init() {
+	RegisterAuthPlugin(nativePasswordAuthPlugin{})
+	RegisterAuthPlugin(clearPasswordAuthPlugin{})
+	RegisterAuthPlugin(sha256PasswordAuthPlugin{})
+	RegisterAuthPlugin(cachingSha2PasswordAuthPlugin{})
+	RegisterAuthPlugin(mariadbEd25519AuthPlugin{})
+}
+
+// nativePasswordAuthPlugin implements mysql_native_password.
+type nativePasswordAuthPlugin struct{}
+
+func (nativePasswordAuthPlugin) Name() string { return mysql.AUTH_NATIVE_PASSWORD }
+
+func (nativePasswordAuthPlugin) InitialResponse(salt []byte, conn *Conn) ([]byte, error) {
+	return mysql.CalcPassword(salt[:20], []byte(conn.password)), nil
+}
+
+func (nativePasswordAuthPlugin) Next(serverData []byte) ([]byte, bool, error) {
+	return nil, true, nil
+}
+
+func (nativePasswordAuthPlugin) NeedsTLS() bool { return false }
+
+// clearPasswordAuthPlugin implements mysql_clear_password.
+type clearPasswordAuthPlugin struct{}
+
+func (clearPasswordAuthPlugin) Name() string { return mysql.AUTH_CLEAR_PASSWORD }
+
+func (clearPasswordAuthPlugin) InitialResponse(salt []byte, conn *Conn) ([]byte, error) {
+	return []byte(conn.password), nil
+}
+
+func (clearPasswordAuthPlugin) Next(serverData []byte) ([]byte, bool, error) {
+	return nil, true, nil
+}
+
+func (clearPasswordAuthPlugin) NeedsTLS() bool { return true }
+
+// sha256PasswordAuthPlugin implements sha256_password, including the
+// public-key-retrieval round trip used when the channel is neither TLS nor
+// a unix socket.
+type sha256PasswordAuthPlugin struct{}
+
+func (sha256PasswordAuthPlugin) Name() string { return mysql.AUTH_SHA256_PASSWORD }
+
+func (sha256PasswordAuthPlugin) InitialResponse(salt []byte, conn *Conn) ([]byte, error) {
+	if len(conn.password) == 0 {
+		return nil, nil
+	}
+	if conn.tlsConfig != nil || conn.proto == "unix" {
+		// write cleartext auth packet
+		// see: https://dev.mysql.com/doc/refman/8.0/en/sha256-pluggable-authentication.html
+		return []byte(conn.password), nil
+	}
+	if key := lookupServerPublicKey(conn); key != nil {
+		// a pinned/cached key lets us skip the {1} round trip entirely
+		return encryptPasswordWithKey(conn.password, salt, key)
+	}
+	// request public key from server
+	// see: https://dev.mysql.com/doc/internals/en/public-key-retrieval.html
+	return []byte{1}, nil
+}
+
+func (sha256PasswordAuthPlugin) Next(serverData []byte) ([]byte, bool, error) {
+	return nil, true, nil
+}
+
+func (sha256PasswordAuthPlugin) NeedsTLS() bool { return false }
+
+// cachingSha2PasswordAuthPlugin implements caching_sha2_password. The
+// initial response is always the fast-auth scrambled hash, as the protocol
+// requires; if the server reports a cache miss it follows up with a
+// "more data" packet, which Conn.continueRSAAuth answers and where a
+// pinned/cached RSA public key (see SetServerPublicKey) is actually put to
+// use to skip the full-auth public-key-retrieval round trip.
+type cachingSha2PasswordAuthPlugin struct{}
+
+func (cachingSha2PasswordAuthPlugin) Name() string { return mysql.AUTH_CACHING_SHA2_PASSWORD }
+
+func (cachingSha2PasswordAuthPlugin) InitialResponse(salt []byte, conn *Conn) ([]byte, error) {
+	return mysql.CalcCachingSha2Password(salt, conn.password), nil
+}
+
+func (cachingSha2PasswordAuthPlugin) Next(serverData []byte) ([]byte, bool, error) {
+	return nil, true, nil
+}
+
+func (cachingSha2PasswordAuthPlugin) NeedsTLS() bool { return false }
+
+// mariadbEd25519AuthPlugin implements MariaDB's client_ed25519 plugin.
+type mariadbEd25519AuthPlugin struct{}
+
+func (mariadbEd25519AuthPlugin) Name() string { return mysql.AUTH_MARIADB_ED25519 }
+
+func (mariadbEd25519AuthPlugin) InitialResponse(salt []byte, conn *Conn) ([]byte, error) {
+	if len(salt) != 32 {
+		return nil, mysql.ErrMalformPacket
+	}
+	return mysql.CalcEd25519Password(salt, conn.password)
+}
+
+func (mariadbEd25519AuthPlugin) Next(serverData []byte) ([]byte, bool, error) {
+	return nil, true, nil
+}
+
+func (mariadbEd25519AuthPlugin) NeedsTLS() bool { return false }
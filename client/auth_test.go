@@ -0,0 +1,29 @@
+package client
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/packet"
+)
+
+// TestWriteAuthHandshakeRejectsTLSRequiredDefaultPlugin is a regression test
+// for a downgrade where a server naming mysql_clear_password (or any other
+// NeedsTLS plugin) as its default plugin in the initial handshake was used
+// over a plain socket without any check, unlike the equivalent guard
+// runAuthHandshake already applies on AuthSwitchRequest.
+func TestWriteAuthHandshakeRejectsTLSRequiredDefaultPlugin(t *testing.T) {
+	clientSide, _ := net.Pipe()
+	c := &Conn{
+		Conn:           packet.NewConnWithTimeout(clientSide, 0, 0, 0),
+		user:           "root",
+		password:       "secret",
+		authPluginName: mysql.AUTH_CLEAR_PASSWORD,
+	}
+
+	err := c.writeAuthHandshake()
+	if err == nil {
+		t.Fatal("expected writeAuthHandshake to refuse mysql_clear_password over a plain socket")
+	}
+}
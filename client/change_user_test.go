@@ -0,0 +1,124 @@
+package client
+
+import (
+	"net"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/packet"
+)
+
+// newTestConnPair returns a Conn wired to one end of an in-memory pipe, and
+// the bare *packet.Conn wrapping the other end for a test to play server.
+func newTestConnPair(t *testing.T) (*Conn, *packet.Conn) {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	c := &Conn{
+		Conn:           packet.NewConnWithTimeout(clientSide, 0, 0, 0),
+		user:           "root",
+		password:       "secret",
+		authPluginName: mysql.AUTH_CACHING_SHA2_PASSWORD,
+		salt:           []byte("01234567890123456789"),
+	}
+	return c, packet.NewConnWithTimeout(serverSide, 0, 0, 0)
+}
+
+// TestRunAuthHandshakeMoreDataRespSent is a regression test for a bug where
+// runAuthHandshake dropped continueRSAAuth's encrypted-password response
+// whenever continueAuth reported done=true, leaving the client silently
+// stuck waiting on the server's OK/ERR packet instead of ever sending it.
+func TestRunAuthHandshakeMoreDataRespSent(t *testing.T) {
+	c, srv := newTestConnPair(t)
+
+	key, err := generateTestRSAKey()
+	if err != nil {
+		t.Fatalf("generate test key: %v", err)
+	}
+	c.SetServerPublicKey(&key.PublicKey)
+
+	done := make(chan error, 1)
+	go func() { done <- c.runAuthHandshake() }()
+
+	// server asks for full auth
+	if err := srv.WritePacket([]byte{authMoreDataHeader, cachingSha2FullAuthRequired}); err != nil {
+		t.Fatalf("write more-data: %v", err)
+	}
+
+	// the client must now write the RSA-encrypted password, not hang
+	encrypted, err := srv.ReadPacket()
+	if err != nil {
+		t.Fatalf("client never sent its encrypted password: %v", err)
+	}
+	if len(encrypted) == 0 {
+		t.Fatal("expected non-empty encrypted password packet")
+	}
+
+	if err := srv.WritePacket([]byte{mysql.OK_HEADER}); err != nil {
+		t.Fatalf("write OK: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("runAuthHandshake returned error: %v", err)
+	}
+}
+
+// TestRunAuthHandshakeFollowsAuthSwitchRequest exercises the general
+// ChangeUser/Connect auth loop: the server switches the client to a
+// different plugin mid-handshake and runAuthHandshake must answer with that
+// plugin's auth response and accept the final OK.
+func TestRunAuthHandshakeFollowsAuthSwitchRequest(t *testing.T) {
+	c, srv := newTestConnPair(t)
+	c.authPluginName = mysql.AUTH_CACHING_SHA2_PASSWORD
+
+	done := make(chan error, 1)
+	go func() { done <- c.runAuthHandshake() }()
+
+	switchSalt := []byte("abcdefghijklmnopqrst")
+	var switchReq []byte
+	switchReq = append(switchReq, mysql.AuthSwitchRequestHeader)
+	switchReq = append(switchReq, []byte(mysql.AUTH_NATIVE_PASSWORD)...)
+	switchReq = append(switchReq, 0x00)
+	switchReq = append(switchReq, switchSalt...)
+	if err := srv.WritePacket(switchReq); err != nil {
+		t.Fatalf("write AuthSwitchRequest: %v", err)
+	}
+
+	if _, err := srv.ReadPacket(); err != nil {
+		t.Fatalf("client never answered the auth switch: %v", err)
+	}
+	if c.authPluginName != mysql.AUTH_NATIVE_PASSWORD {
+		t.Fatalf("authPluginName = %q, want %q", c.authPluginName, mysql.AUTH_NATIVE_PASSWORD)
+	}
+
+	if err := srv.WritePacket([]byte{mysql.OK_HEADER}); err != nil {
+		t.Fatalf("write OK: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("runAuthHandshake returned error: %v", err)
+	}
+}
+
+// TestRunAuthHandshakeRejectsTLSRequiredSwitch confirms the NeedsTLS
+// downgrade protection on AuthSwitchRequest: switching to mysql_clear_password
+// over a plain socket must be refused rather than sent.
+func TestRunAuthHandshakeRejectsTLSRequiredSwitch(t *testing.T) {
+	c, srv := newTestConnPair(t)
+	c.authPluginName = mysql.AUTH_CACHING_SHA2_PASSWORD
+
+	done := make(chan error, 1)
+	go func() { done <- c.runAuthHandshake() }()
+
+	var switchReq []byte
+	switchReq = append(switchReq, mysql.AuthSwitchRequestHeader)
+	switchReq = append(switchReq, []byte(mysql.AUTH_CLEAR_PASSWORD)...)
+	switchReq = append(switchReq, 0x00)
+	switchReq = append(switchReq, []byte("abcdefghijklmnopqrst")...)
+	if err := srv.WritePacket(switchReq); err != nil {
+		t.Fatalf("write AuthSwitchRequest: %v", err)
+	}
+
+	if err := <-done; err == nil {
+		t.Fatal("expected runAuthHandshake to refuse switching to mysql_clear_password over a plain socket")
+	}
+}
@@ -0,0 +1,182 @@
+package client
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/errors"
+)
+
+// DefaultMaxLocalInfileSize caps how many bytes RegisterLocalFile/
+// RegisterReaderHandler will stream back for a single LOAD DATA LOCAL
+// INFILE, unless overridden with Conn.SetMaxLocalInfileSize. It guards
+// against a compromised or misconfigured server asking the client to read
+// an unbounded amount of local data.
+const DefaultMaxLocalInfileSize = 1 << 30 // 1 GiB
+
+var localFilesLock sync.RWMutex
+var localFiles = make(map[string]struct{})
+var readerHandlers = make(map[string]func() io.Reader)
+
+// RegisterLocalFile allowlists name to be sent in response to a
+// LOAD DATA LOCAL INFILE '<name>' request, matching it by the absolute path
+// the server echoes back. It is the client-side equivalent of
+// go-sql-driver/mysql's RegisterLocalFile.
+func RegisterLocalFile(name string) {
+	localFilesLock.Lock()
+	defer localFilesLock.Unlock()
+	localFiles[name] = struct{}{}
+}
+
+// DeregisterLocalFile removes name from the allowlist built by
+// RegisterLocalFile.
+func DeregisterLocalFile(name string) {
+	localFilesLock.Lock()
+	defer localFilesLock.Unlock()
+	delete(localFiles, name)
+}
+
+// RegisterReaderHandler registers fn under name so that
+// LOAD DATA LOCAL INFILE 'Reader::<name>' streams whatever io.Reader fn
+// returns instead of reading a file from disk.
+func RegisterReaderHandler(name string, fn func() io.Reader) {
+	localFilesLock.Lock()
+	defer localFilesLock.Unlock()
+	readerHandlers[name] = fn
+}
+
+// DeregisterReaderHandler removes the reader handler registered under name.
+func DeregisterReaderHandler(name string) {
+	localFilesLock.Lock()
+	defer localFilesLock.Unlock()
+	delete(readerHandlers, name)
+}
+
+const readerHandlerPrefix = "Reader::"
+
+// SetMaxLocalInfileSize overrides DefaultMaxLocalInfileSize for conn.
+func (c *Conn) SetMaxLocalInfileSize(n int64) {
+	c.maxLocalInfileSize = n
+}
+
+func (c *Conn) maxLocalInfileSizeOrDefault() int64 {
+	if c.maxLocalInfileSize > 0 {
+		return c.maxLocalInfileSize
+	}
+	return DefaultMaxLocalInfileSize
+}
+
+// openLocalInFile resolves name - as sent back by the server in a
+// LocalInFile request packet - to an io.Reader, honoring
+// RegisterReaderHandler's "Reader::" namespace and otherwise requiring an
+// exact match against RegisterLocalFile's allowlist to prevent a malicious
+// or compromised server from reading arbitrary local files (path
+// traversal).
+func (c *Conn) openLocalInFile(name string) (io.Reader, error) {
+	if rest, ok := stripPrefix(name, readerHandlerPrefix); ok {
+		localFilesLock.RLock()
+		fn, ok := readerHandlers[rest]
+		localFilesLock.RUnlock()
+		if !ok {
+			return nil, errors.Errorf("local reader handler %q is not registered", rest)
+		}
+		return fn(), nil
+	}
+
+	localFilesLock.RLock()
+	_, allowed := localFiles[name]
+	localFilesLock.RUnlock()
+	if !allowed {
+		return nil, errors.Errorf("local file %q is not allowlisted, see client.RegisterLocalFile", name)
+	}
+
+	clean := filepath.Clean(name)
+	if clean != name {
+		return nil, errors.Errorf("local file %q is not allowlisted, see client.RegisterLocalFile", name)
+	}
+
+	f, err := os.Open(clean)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return f, nil
+}
+
+func stripPrefix(s, prefix string) (string, bool) {
+	if len(s) < len(prefix) || s[:len(prefix)] != prefix {
+		return "", false
+	}
+	return s[len(prefix):], true
+}
+
+// handleInFileRequest answers a LocalInFile request packet (the server's
+// 0xFB response to LOAD DATA LOCAL INFILE) by streaming name in chunks of
+// at most mysql.MaxPayloadLen bytes, followed by an empty packet, and
+// then consuming the statement's terminal OK/ERR packet.
+//
+// See: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query_response_local_infile_request.html
+func (c *Conn) handleInFileRequest(name string) error {
+	reader, err := c.openLocalInFile(name)
+	if closer, ok := reader.(io.Closer); ok {
+		defer closer.Close()
+	}
+	if err != nil {
+		// the protocol still expects an (empty) packet even when the
+		// client refuses to honor the request; the server then reports
+		// the failure itself via the terminal ERR packet.
+		if werr := c.WritePacket([]byte{}); werr != nil {
+			return errors.Trace(werr)
+		}
+		if _, rerr := c.readResultOK(); rerr != nil {
+			return errors.Trace(rerr)
+		}
+		return err
+	}
+
+	// Read one byte past the cap so a file that is exactly at the cap
+	// still succeeds: an io.EOF that surfaces only on a following,
+	// separate Read (as os.File and many other readers commonly do)
+	// must not be mistaken for an overflow.
+	limit := c.maxLocalInfileSizeOrDefault()
+	limited := io.LimitReader(reader, limit+1)
+	var sent int64
+	buf := make([]byte, mysql.MaxPayloadLen)
+	for {
+		n, rerr := limited.Read(buf)
+		if n > 0 {
+			sent += int64(n)
+			if sent > limit {
+				// the server is still waiting on a terminating empty
+				// packet no matter why we stop sending data, or the
+				// connection's packet sequence desyncs for whatever
+				// statement comes next.
+				if werr := c.WritePacket([]byte{}); werr != nil {
+					return errors.Trace(werr)
+				}
+				if _, rerr := c.readResultOK(); rerr != nil {
+					return errors.Trace(rerr)
+				}
+				return errors.Errorf("local infile %q exceeds the %d byte size cap", name, limit)
+			}
+			if werr := c.WritePacket(buf[:n]); werr != nil {
+				return errors.Trace(werr)
+			}
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return errors.Trace(rerr)
+		}
+	}
+
+	if err := c.WritePacket([]byte{}); err != nil {
+		return errors.Trace(err)
+	}
+
+	_, err = c.readResultOK()
+	return err
+}
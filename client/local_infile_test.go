@@ -0,0 +1,106 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/packet"
+)
+
+func newLocalInfileTestConn(t *testing.T) (*Conn, *packet.Conn) {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	c := &Conn{Conn: packet.NewConnWithTimeout(clientSide, 0, 0, 0)}
+	return c, packet.NewConnWithTimeout(serverSide, 0, 0, 0)
+}
+
+// drainToEmptyPacket reads packets from srv until it sees the terminating
+// empty packet, returning every byte read in between.
+func drainToEmptyPacket(t *testing.T, srv *packet.Conn) []byte {
+	t.Helper()
+	var got []byte
+	for {
+		p, err := srv.ReadPacket()
+		if err != nil {
+			t.Fatalf("read packet: %v", err)
+		}
+		if len(p) == 0 {
+			return got
+		}
+		got = append(got, p...)
+	}
+}
+
+func TestHandleInFileRequestExactlyAtCap(t *testing.T) {
+	c, srv := newLocalInfileTestConn(t)
+	c.SetMaxLocalInfileSize(4)
+	const name = "exact-cap-file"
+	RegisterReaderHandler(name, func() io.Reader { return bytes.NewReader([]byte("abcd")) })
+	defer DeregisterReaderHandler(name)
+
+	done := make(chan error, 1)
+	go func() { done <- c.handleInFileRequest(readerHandlerPrefix + name) }()
+
+	if got := drainToEmptyPacket(t, srv); string(got) != "abcd" {
+		t.Fatalf("streamed data = %q, want %q", got, "abcd")
+	}
+	if err := srv.WritePacket([]byte{mysql.OK_HEADER}); err != nil {
+		t.Fatalf("write OK: %v", err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatalf("handleInFileRequest returned error for a file exactly at the cap: %v", err)
+	}
+}
+
+// TestHandleInFileRequestOverCapSendsTerminator is a regression test for a
+// bug where exceeding the size cap returned an error without ever sending
+// the terminating empty packet the server is waiting on, desyncing the
+// connection's packet sequence for whatever statement came next.
+func TestHandleInFileRequestOverCapSendsTerminator(t *testing.T) {
+	c, srv := newLocalInfileTestConn(t)
+	c.SetMaxLocalInfileSize(4)
+	const name = "over-cap-file"
+	RegisterReaderHandler(name, func() io.Reader { return bytes.NewReader([]byte("abcde")) })
+	defer DeregisterReaderHandler(name)
+
+	done := make(chan error, 1)
+	go func() { done <- c.handleInFileRequest(readerHandlerPrefix + name) }()
+
+	drainToEmptyPacket(t, srv) // must terminate, not hang
+	if err := srv.WritePacket([]byte{mysql.OK_HEADER}); err != nil {
+		t.Fatalf("write OK: %v", err)
+	}
+
+	if err := <-done; err == nil {
+		t.Fatal("expected handleInFileRequest to report the size cap was exceeded")
+	}
+}
+
+func TestOpenLocalInFileRejectsUnlistedPath(t *testing.T) {
+	c := &Conn{}
+	if _, err := c.openLocalInFile("/etc/passwd"); err == nil {
+		t.Fatal("expected an error for a path that was never allowlisted")
+	}
+}
+
+func TestOpenLocalInFileAllowsRegisteredPath(t *testing.T) {
+	c := &Conn{}
+	const name = "/tmp/allowed-file"
+	RegisterLocalFile(name)
+	defer DeregisterLocalFile(name)
+
+	// the file need not exist on disk for the allowlist check itself to
+	// pass; os.Open failing afterwards is a distinct, expected error that
+	// must not be the allowlist rejection message.
+	_, err := c.openLocalInFile(name)
+	if err == nil {
+		t.Fatal("expected os.Open to fail for a nonexistent allowlisted file")
+	}
+	if bytes.Contains([]byte(err.Error()), []byte("not allowlisted")) {
+		t.Fatalf("registered path was rejected as not allowlisted: %v", err)
+	}
+}
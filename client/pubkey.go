@@ -0,0 +1,172 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/pingcap/errors"
+)
+
+var serverPubKeyLock sync.RWMutex
+var serverPubKeys = make(map[string]*rsa.PublicKey)
+
+// SetServerPublicKey pins the RSA public key used to encrypt the password
+// for sha256_password / caching_sha2_password full authentication when the
+// connection is neither TLS nor a unix socket. Pinning the key at startup,
+// instead of fetching it from the server with a {1} request on every
+// connect, saves a round trip - matching go-sql-driver/mysql's ServerPubKey
+// mechanism.
+func (c *Conn) SetServerPublicKey(pub *rsa.PublicKey) {
+	c.serverPubKey = pub
+}
+
+// SetServerPublicKeyPEMFile is a convenience wrapper around
+// SetServerPublicKey that reads and parses a PEM-encoded RSA public key
+// file, such as the one produced by mysql_ssl_rsa_setup or copied from the
+// server's public_key.pem.
+func (c *Conn) SetServerPublicKeyPEMFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	key, err := parsePublicKey(data)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.serverPubKey = key
+	return nil
+}
+
+// cacheServerPublicKey stores the RSA public key fetched from the server at
+// addr (host:port) in the process-wide cache, so subsequent connections to
+// the same server can skip the public-key-retrieval round trip. It is
+// called once a key obtained via the {1}/more-data exchange has been
+// parsed.
+func cacheServerPublicKey(addr string, key *rsa.PublicKey) {
+	serverPubKeyLock.Lock()
+	defer serverPubKeyLock.Unlock()
+	serverPubKeys[addr] = key
+}
+
+// cachedServerPublicKey returns the previously cached RSA public key for
+// addr, if any.
+func cachedServerPublicKey(addr string) *rsa.PublicKey {
+	serverPubKeyLock.RLock()
+	defer serverPubKeyLock.RUnlock()
+	return serverPubKeys[addr]
+}
+
+// lookupServerPublicKey returns the key pinned directly on conn via
+// SetServerPublicKey/SetServerPublicKeyPEMFile, falling back to the
+// process-wide cache keyed by conn.addr.
+func lookupServerPublicKey(conn *Conn) *rsa.PublicKey {
+	if conn.serverPubKey != nil {
+		return conn.serverPubKey
+	}
+	return cachedServerPublicKey(conn.addr)
+}
+
+// parsePublicKey decodes a PEM-encoded RSA public key, as sent by the
+// server during public-key-retrieval or loaded from a file.
+func parsePublicKey(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in server public key")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	key, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("server public key is not an RSA key, got %T", pub)
+	}
+	return key, nil
+}
+
+// cachingSha2 "more data" status bytes.
+// See: https://dev.mysql.com/doc/dev/mysql-server/latest/page_caching_sha2_authentication_exchanges.html
+const (
+	cachingSha2FastAuthSuccess  = 0x03
+	cachingSha2FullAuthRequired = 0x04
+)
+
+// continueAuth answers a "more data" / auth-method-data packet received
+// mid-handshake, for both the initial connect and ChangeUser. sha256_password
+// and caching_sha2_password full authentication is handled here directly, so
+// it can go through the same pinned/cached RSA key as InitialResponse and
+// populate the cache from whatever key the server sends back; any other
+// registered plugin's own Next is invoked, so a caller-supplied AuthPlugin
+// (Kerberos, PAM, ...) drives its own continuation.
+func (c *Conn) continueAuth(serverData []byte) ([]byte, bool, error) {
+	switch c.authPluginName {
+	case mysql.AUTH_SHA256_PASSWORD, mysql.AUTH_CACHING_SHA2_PASSWORD:
+		return c.continueRSAAuth(serverData)
+	default:
+		plugin, ok := getAuthPlugin(c.authPluginName)
+		if !ok {
+			return nil, false, fmt.Errorf("auth plugin '%s' is not supported", c.authPluginName)
+		}
+		return plugin.Next(serverData)
+	}
+}
+
+// continueRSAAuth drives caching_sha2_password's fast/full-auth status
+// bytes and the public-key-retrieval round trip shared by sha256_password
+// and caching_sha2_password full authentication.
+func (c *Conn) continueRSAAuth(serverData []byte) ([]byte, bool, error) {
+	if c.authPluginName == mysql.AUTH_CACHING_SHA2_PASSWORD && len(serverData) == 1 {
+		switch serverData[0] {
+		case cachingSha2FastAuthSuccess:
+			return nil, true, nil
+		case cachingSha2FullAuthRequired:
+			if c.tlsConfig != nil || c.proto == "unix" {
+				return append([]byte(c.password), 0x00), false, nil
+			}
+			if key := lookupServerPublicKey(c); key != nil {
+				enc, err := encryptPasswordWithKey(c.password, c.salt, key)
+				return enc, true, err
+			}
+			// request public key from server
+			return []byte{1}, false, nil
+		}
+	}
+
+	// otherwise serverData is the server's PEM-encoded RSA public key,
+	// sent in response to our {1} request
+	key, err := parsePublicKey(serverData)
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	cacheServerPublicKey(c.addr, key)
+
+	enc, err := encryptPasswordWithKey(c.password, c.salt, key)
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	return enc, true, nil
+}
+
+// encryptPasswordWithKey XORs the NUL-terminated password against the
+// repeated scramble and RSA-OAEP encrypts it with the server's public key,
+// the scheme sha256_password/caching_sha2_password use to send the password
+// without TLS.
+func encryptPasswordWithKey(password string, scramble []byte, pub *rsa.PublicKey) ([]byte, error) {
+	plain := make([]byte, len(password)+1)
+	copy(plain, password)
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	enc, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, plain, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return enc, nil
+}
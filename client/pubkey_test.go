@@ -0,0 +1,85 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+)
+
+func generateTestRSAKey() (*rsa.PrivateKey, error) {
+	return rsa.GenerateKey(rand.Reader, 2048)
+}
+
+func pemEncodePublicKey(t *testing.T, pub *rsa.PublicKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestParsePublicKeyRoundTrip(t *testing.T) {
+	key, err := generateTestRSAKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	parsed, err := parsePublicKey(pemEncodePublicKey(t, &key.PublicKey))
+	if err != nil {
+		t.Fatalf("parsePublicKey: %v", err)
+	}
+	if !parsed.Equal(&key.PublicKey) {
+		t.Fatal("parsed public key does not match the original")
+	}
+}
+
+func TestParsePublicKeyRejectsGarbage(t *testing.T) {
+	if _, err := parsePublicKey([]byte("not a pem block")); err == nil {
+		t.Fatal("expected an error for non-PEM input")
+	}
+}
+
+func TestEncryptPasswordWithKeyDecrypts(t *testing.T) {
+	key, err := generateTestRSAKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	scramble := []byte("0123456789abcdefghij")
+
+	enc, err := encryptPasswordWithKey("s3cret", scramble, &key.PublicKey)
+	if err != nil {
+		t.Fatalf("encryptPasswordWithKey: %v", err)
+	}
+
+	plain, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, enc, nil)
+	if err != nil {
+		t.Fatalf("DecryptOAEP: %v", err)
+	}
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	if string(plain) != "s3cret\x00" {
+		t.Fatalf("decrypted password = %q, want %q", plain, "s3cret\x00")
+	}
+}
+
+func TestServerPublicKeyCache(t *testing.T) {
+	key, err := generateTestRSAKey()
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	const addr = "test-cache-addr:3306"
+	if got := cachedServerPublicKey(addr); got != nil {
+		t.Fatalf("expected no cached key for %s before caching, got %v", addr, got)
+	}
+
+	cacheServerPublicKey(addr, &key.PublicKey)
+	if got := cachedServerPublicKey(addr); !got.Equal(&key.PublicKey) {
+		t.Fatal("cachedServerPublicKey did not return the cached key")
+	}
+}
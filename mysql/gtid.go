@@ -20,6 +20,25 @@ type GTIDSet interface {
 
 	// IsEmpty returns true if the given set is empty and false otherwise.
 	IsEmpty() bool
+
+	// AddSet merges every GTID in o into the set, in place, equivalent to
+	// calling Update for each of o's GTIDs. o must be of the same
+	// concrete type (flavor) as the receiver.
+	AddSet(o GTIDSet) error
+
+	// Union returns a new set containing every GTID present in either the
+	// receiver or o, leaving both unmodified.
+	Union(o GTIDSet) GTIDSet
+
+	// Intersect returns a new set containing only the GTIDs present in
+	// both the receiver and o - the part of the receiver that o already
+	// has.
+	Intersect(o GTIDSet) GTIDSet
+
+	// Subtract returns a new set containing the GTIDs in the receiver
+	// that are not present in o - the part of the receiver that o is
+	// missing.
+	Subtract(o GTIDSet) GTIDSet
 }
 
 func ParseGTIDSet(flavor string, s string) (GTIDSet, error) {
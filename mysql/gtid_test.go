@@ -0,0 +1,181 @@
+package mysql
+
+import "testing"
+
+func TestMysqlGTIDSetAlgebra(t *testing.T) {
+	uuidStr := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+
+	cases := []struct {
+		name         string
+		a, b         string
+		wantUnion    string
+		wantIntersect string
+		wantSubtract string
+	}{
+		{
+			name:          "overlapping",
+			a:             uuidStr + ":1-10",
+			b:             uuidStr + ":5-15",
+			wantUnion:     uuidStr + ":1-15",
+			wantIntersect: uuidStr + ":5-10",
+			wantSubtract:  uuidStr + ":1-4",
+		},
+		{
+			name:          "adjacent",
+			a:             uuidStr + ":1-5",
+			b:             uuidStr + ":6-10",
+			wantUnion:     uuidStr + ":1-10",
+			wantIntersect: "",
+			wantSubtract:  uuidStr + ":1-5",
+		},
+		{
+			name:          "disjoint",
+			a:             uuidStr + ":1-5",
+			b:             uuidStr + ":20-25",
+			wantUnion:     uuidStr + ":1-5:20-25",
+			wantIntersect: "",
+			wantSubtract:  uuidStr + ":1-5",
+		},
+		{
+			name:          "nested",
+			a:             uuidStr + ":1-20",
+			b:             uuidStr + ":5-10",
+			wantUnion:     uuidStr + ":1-20",
+			wantIntersect: uuidStr + ":5-10",
+			wantSubtract:  uuidStr + ":1-4:11-20",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a, err := ParseMysqlGTIDSet(c.a)
+			if err != nil {
+				t.Fatalf("ParseMysqlGTIDSet(%q): %v", c.a, err)
+			}
+			b, err := ParseMysqlGTIDSet(c.b)
+			if err != nil {
+				t.Fatalf("ParseMysqlGTIDSet(%q): %v", c.b, err)
+			}
+
+			if got := a.Union(b).String(); got != c.wantUnion {
+				t.Errorf("Union = %q, want %q", got, c.wantUnion)
+			}
+			if got := a.Intersect(b).String(); got != c.wantIntersect {
+				t.Errorf("Intersect = %q, want %q", got, c.wantIntersect)
+			}
+			if got := a.Subtract(b).String(); got != c.wantSubtract {
+				t.Errorf("Subtract = %q, want %q", got, c.wantSubtract)
+			}
+
+			if got := a.Intersect(b).(*MysqlGTIDSet).IsEmpty(); (c.wantIntersect == "") != got {
+				t.Errorf("Intersect IsEmpty = %v, want %v", got, c.wantIntersect == "")
+			}
+		})
+	}
+
+	t.Run("AddSet merges in place", func(t *testing.T) {
+		a, _ := ParseMysqlGTIDSet(uuidStr + ":1-5")
+		b, _ := ParseMysqlGTIDSet(uuidStr + ":6-10")
+		if err := a.AddSet(b); err != nil {
+			t.Fatalf("AddSet: %v", err)
+		}
+		if want := uuidStr + ":1-10"; a.String() != want {
+			t.Errorf("AddSet result = %q, want %q", a.String(), want)
+		}
+	})
+
+	t.Run("Subtract leaving nothing is empty", func(t *testing.T) {
+		a, _ := ParseMysqlGTIDSet(uuidStr + ":1-10")
+		b, _ := ParseMysqlGTIDSet(uuidStr + ":1-10")
+		diff := a.Subtract(b).(*MysqlGTIDSet)
+		if !diff.IsEmpty() {
+			t.Errorf("Subtract of identical sets = %q, want empty", diff.String())
+		}
+	})
+}
+
+func TestMariadbGTIDSetAlgebra(t *testing.T) {
+	cases := []struct {
+		name          string
+		a, b          string
+		wantUnion     string
+		wantIntersect string
+		wantSubtract  string
+	}{
+		{
+			name:          "a ahead of b in shared domain",
+			a:              "0-1-100",
+			b:              "0-1-1",
+			wantUnion:      "0-1-100",
+			wantIntersect:  "0-1-1",
+			wantSubtract:   "0-1-100",
+		},
+		{
+			name:          "b ahead of a in shared domain",
+			a:              "0-1-1",
+			b:              "0-1-100",
+			wantUnion:      "0-1-100",
+			wantIntersect:  "0-1-1",
+			wantSubtract:   "",
+		},
+		{
+			name:          "equal sequence numbers are fully covered",
+			a:              "0-1-50",
+			b:              "0-1-50",
+			wantUnion:      "0-1-50",
+			wantIntersect:  "0-1-50",
+			wantSubtract:   "",
+		},
+		{
+			name:          "disjoint domains",
+			a:              "0-1-5",
+			b:              "1-1-5",
+			wantUnion:      "0-1-5,1-1-5",
+			wantIntersect:  "",
+			wantSubtract:   "0-1-5",
+		},
+		{
+			name:          "nested: multiple domains, one shared one not",
+			a:              "0-1-10,1-1-10",
+			b:              "0-1-3",
+			wantUnion:      "0-1-10,1-1-10",
+			wantIntersect:  "0-1-3",
+			wantSubtract:   "0-1-10,1-1-10",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			a, err := ParseMariadbGTIDSet(c.a)
+			if err != nil {
+				t.Fatalf("ParseMariadbGTIDSet(%q): %v", c.a, err)
+			}
+			b, err := ParseMariadbGTIDSet(c.b)
+			if err != nil {
+				t.Fatalf("ParseMariadbGTIDSet(%q): %v", c.b, err)
+			}
+
+			if got := a.Union(b).String(); got != c.wantUnion {
+				t.Errorf("Union = %q, want %q", got, c.wantUnion)
+			}
+			if got := a.Intersect(b).String(); got != c.wantIntersect {
+				t.Errorf("Intersect = %q, want %q", got, c.wantIntersect)
+			}
+			if got := a.Subtract(b).String(); got != c.wantSubtract {
+				t.Errorf("Subtract = %q, want %q", got, c.wantSubtract)
+			}
+		})
+	}
+
+	t.Run("Subtract keeps a domain o is behind on, by the receiver's sequence number", func(t *testing.T) {
+		a, _ := ParseMariadbGTIDSet("0-1-100")
+		b, _ := ParseMariadbGTIDSet("0-1-1")
+		diff := a.Subtract(b).(*MariadbGTIDSet)
+		if diff.IsEmpty() {
+			t.Fatalf("Subtract = empty, want domain 0 to remain since b is far behind a")
+		}
+		if got := diff.Sets[0].SequenceNumber; got != 100 {
+			t.Errorf("Subtract kept sequence number %d, want 100", got)
+		}
+	})
+}
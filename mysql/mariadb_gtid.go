@@ -0,0 +1,208 @@
+package mysql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pingcap/errors"
+)
+
+// MariadbGTID is a single MariaDB GTID: Domain-Server-Sequence.
+//
+// See: https://mariadb.com/kb/en/gtid/
+type MariadbGTID struct {
+	DomainID       uint32
+	ServerID       uint32
+	SequenceNumber uint64
+}
+
+// ParseMariadbGTID parses "domain-server-sequence".
+func ParseMariadbGTID(s string) (*MariadbGTID, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 3 {
+		return nil, errors.Errorf("invalid Mariadb GTID %q", s)
+	}
+	domainID, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid domain id in %q", s)
+	}
+	serverID, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid server id in %q", s)
+	}
+	seq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return nil, errors.Annotatef(err, "invalid sequence number in %q", s)
+	}
+	return &MariadbGTID{DomainID: uint32(domainID), ServerID: uint32(serverID), SequenceNumber: seq}, nil
+}
+
+func (gtid *MariadbGTID) String() string {
+	return fmt.Sprintf("%d-%d-%d", gtid.DomainID, gtid.ServerID, gtid.SequenceNumber)
+}
+
+func (gtid *MariadbGTID) Clone() *MariadbGTID {
+	clone := *gtid
+	return &clone
+}
+
+// MariadbGTIDSet is a MariaDB GTID set: one MariadbGTID per domain id, the
+// latest (server id, sequence number) that domain has reached.
+type MariadbGTIDSet struct {
+	Sets map[uint32]*MariadbGTID
+}
+
+// ParseMariadbGTIDSet parses a comma-separated MariaDB GTID set, e.g.
+// "0-1-1,1-2-3".
+func ParseMariadbGTIDSet(s string) (*MariadbGTIDSet, error) {
+	sets := make(map[uint32]*MariadbGTID)
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return &MariadbGTIDSet{Sets: sets}, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		gtid, err := ParseMariadbGTID(part)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if existing, ok := sets[gtid.DomainID]; !ok || gtid.SequenceNumber > existing.SequenceNumber {
+			sets[gtid.DomainID] = gtid
+		}
+	}
+	return &MariadbGTIDSet{Sets: sets}, nil
+}
+
+func (s *MariadbGTIDSet) String() string {
+	gtids := make([]string, 0, len(s.Sets))
+	for _, gtid := range s.Sets {
+		gtids = append(gtids, gtid.String())
+	}
+	sort.Strings(gtids)
+	return strings.Join(gtids, ",")
+}
+
+func (s *MariadbGTIDSet) Encode() []byte {
+	return []byte(s.String())
+}
+
+func (s *MariadbGTIDSet) Equal(o GTIDSet) bool {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return false
+	}
+	if len(s.Sets) != len(other.Sets) {
+		return false
+	}
+	for domain, gtid := range s.Sets {
+		ogtid, ok := other.Sets[domain]
+		if !ok || *ogtid != *gtid {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MariadbGTIDSet) Contain(o GTIDSet) bool {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return false
+	}
+	for domain, ogtid := range other.Sets {
+		gtid, ok := s.Sets[domain]
+		if !ok || gtid.SequenceNumber < ogtid.SequenceNumber {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MariadbGTIDSet) Update(gtidStr string) error {
+	sets, err := ParseMariadbGTIDSet(gtidStr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return s.AddSet(sets)
+}
+
+func (s *MariadbGTIDSet) AddSet(o GTIDSet) error {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return errors.Errorf("invalid GTIDSet type %T to merge into MariadbGTIDSet", o)
+	}
+	for domain, ogtid := range other.Sets {
+		if gtid, ok := s.Sets[domain]; !ok || ogtid.SequenceNumber > gtid.SequenceNumber {
+			s.Sets[domain] = ogtid.Clone()
+		}
+	}
+	return nil
+}
+
+// Union returns the per-domain maximum of the receiver and o: whichever of
+// the two has advanced further in each domain.
+func (s *MariadbGTIDSet) Union(o GTIDSet) GTIDSet {
+	clone := s.Clone().(*MariadbGTIDSet)
+	// AddSet never fails for a *MariadbGTIDSet argument.
+	_ = clone.AddSet(o)
+	return clone
+}
+
+// Intersect returns, for every domain present in both sets, the GTID with
+// the smaller sequence number - the point both sets are guaranteed to have
+// replayed up to.
+func (s *MariadbGTIDSet) Intersect(o GTIDSet) GTIDSet {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return &MariadbGTIDSet{Sets: make(map[uint32]*MariadbGTID)}
+	}
+	result := make(map[uint32]*MariadbGTID)
+	for domain, gtid := range s.Sets {
+		ogtid, ok := other.Sets[domain]
+		if !ok {
+			continue
+		}
+		if ogtid.SequenceNumber < gtid.SequenceNumber {
+			result[domain] = ogtid.Clone()
+		} else {
+			result[domain] = gtid.Clone()
+		}
+	}
+	return &MariadbGTIDSet{Sets: result}
+}
+
+// Subtract returns, for every domain in the receiver, what o is missing:
+// the domain is dropped entirely when o has reached the same sequence
+// number or further, and kept (at the receiver's sequence number) when o is
+// behind or has no GTID for that domain at all.
+func (s *MariadbGTIDSet) Subtract(o GTIDSet) GTIDSet {
+	other, ok := o.(*MariadbGTIDSet)
+	if !ok {
+		return s.Clone()
+	}
+	result := make(map[uint32]*MariadbGTID)
+	for domain, gtid := range s.Sets {
+		ogtid, ok := other.Sets[domain]
+		if ok && ogtid.SequenceNumber >= gtid.SequenceNumber {
+			continue
+		}
+		result[domain] = gtid.Clone()
+	}
+	return &MariadbGTIDSet{Sets: result}
+}
+
+func (s *MariadbGTIDSet) Clone() GTIDSet {
+	clone := &MariadbGTIDSet{Sets: make(map[uint32]*MariadbGTID, len(s.Sets))}
+	for domain, gtid := range s.Sets {
+		clone.Sets[domain] = gtid.Clone()
+	}
+	return clone
+}
+
+func (s *MariadbGTIDSet) IsEmpty() bool {
+	return len(s.Sets) == 0
+}
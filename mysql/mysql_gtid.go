@@ -0,0 +1,422 @@
+package mysql
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pingcap/errors"
+)
+
+// Interval is a half-open range [Start, Stop) of transaction sequence
+// numbers for one server UUID. Stop is exclusive, so the single
+// transaction "5" is represented as Interval{Start: 5, Stop: 6}.
+type Interval struct {
+	Start int64
+	Stop  int64
+}
+
+// IntervalSlice is a sortable list of Interval, ordered by Start.
+type IntervalSlice []Interval
+
+func (s IntervalSlice) Len() int           { return len(s) }
+func (s IntervalSlice) Less(i, j int) bool { return s[i].Start < s[j].Start }
+func (s IntervalSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s IntervalSlice) Sort()              { sort.Sort(s) }
+
+// Normalize sorts the intervals and merges every pair that overlaps or is
+// adjacent (e.g. [1,5) and [5,9) become [1,9)), dropping empty ones.
+func (s IntervalSlice) Normalize() IntervalSlice {
+	if len(s) == 0 {
+		return s
+	}
+	s.Sort()
+
+	out := make(IntervalSlice, 0, len(s))
+	for _, iv := range s {
+		if iv.Stop <= iv.Start {
+			continue
+		}
+		if n := len(out); n > 0 && iv.Start <= out[n-1].Stop {
+			if iv.Stop > out[n-1].Stop {
+				out[n-1].Stop = iv.Stop
+			}
+			continue
+		}
+		out = append(out, iv)
+	}
+	return out
+}
+
+func (s IntervalSlice) String() string {
+	buf := new(bytes.Buffer)
+	for i, iv := range s {
+		if i != 0 {
+			buf.WriteString(":")
+		}
+		if iv.Stop == iv.Start+1 {
+			fmt.Fprintf(buf, "%d", iv.Start)
+		} else {
+			fmt.Fprintf(buf, "%d-%d", iv.Start, iv.Stop-1)
+		}
+	}
+	return buf.String()
+}
+
+func parseInterval(s string) (Interval, error) {
+	parts := strings.SplitN(s, "-", 2)
+	start, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Interval{}, errors.Trace(err)
+	}
+	if len(parts) == 1 {
+		return Interval{Start: start, Stop: start + 1}, nil
+	}
+	stop, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return Interval{}, errors.Trace(err)
+	}
+	return Interval{Start: start, Stop: stop + 1}, nil
+}
+
+// ParseIntervals parses a colon-separated list of ranges, e.g. "1-5:11-14".
+func ParseIntervals(s string) (IntervalSlice, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	parts := strings.Split(s, ":")
+	intervals := make(IntervalSlice, 0, len(parts))
+	for _, p := range parts {
+		iv, err := parseInterval(strings.TrimSpace(p))
+		if err != nil {
+			return nil, errors.Annotatef(err, "invalid interval %q", p)
+		}
+		if iv.Stop <= iv.Start {
+			return nil, errors.Errorf("invalid interval %q, stop must be greater than start", p)
+		}
+		intervals = append(intervals, iv)
+	}
+	return intervals.Normalize(), nil
+}
+
+// unionIntervals merges two already-normalized interval lists.
+func unionIntervals(a, b IntervalSlice) IntervalSlice {
+	merged := make(IntervalSlice, 0, len(a)+len(b))
+	merged = append(merged, a...)
+	merged = append(merged, b...)
+	return merged.Normalize()
+}
+
+// intersectIntervals returns the overlap of two already-normalized interval
+// lists.
+func intersectIntervals(a, b IntervalSlice) IntervalSlice {
+	var out IntervalSlice
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		start := maxInt64(a[i].Start, b[j].Start)
+		stop := minInt64(a[i].Stop, b[j].Stop)
+		if start < stop {
+			out = append(out, Interval{Start: start, Stop: stop})
+		}
+		if a[i].Stop < b[j].Stop {
+			i++
+		} else {
+			j++
+		}
+	}
+	return out.Normalize()
+}
+
+// subtractIntervals returns a minus every interval in b, where both are
+// already-normalized.
+func subtractIntervals(a, b IntervalSlice) IntervalSlice {
+	out := make(IntervalSlice, 0, len(a))
+	for _, iv := range a {
+		cur := []Interval{iv}
+		for _, sub := range b {
+			var next []Interval
+			for _, c := range cur {
+				if sub.Stop <= c.Start || sub.Start >= c.Stop {
+					next = append(next, c)
+					continue
+				}
+				if sub.Start > c.Start {
+					next = append(next, Interval{Start: c.Start, Stop: sub.Start})
+				}
+				if sub.Stop < c.Stop {
+					next = append(next, Interval{Start: sub.Stop, Stop: c.Stop})
+				}
+			}
+			cur = next
+		}
+		out = append(out, cur...)
+	}
+	return out.Normalize()
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// UUIDSet is the set of transactions committed by one server UUID.
+type UUIDSet struct {
+	SID       uuid.UUID
+	Intervals IntervalSlice
+}
+
+// ParseUUIDSet parses "uuid:1-5:11-14".
+func ParseUUIDSet(s string) (*UUIDSet, error) {
+	sep := strings.SplitN(s, ":", 2)
+	if len(sep) != 2 {
+		return nil, errors.Errorf("invalid uuid set %q", s)
+	}
+	sid, err := uuid.Parse(sep[0])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	intervals, err := ParseIntervals(sep[1])
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return &UUIDSet{SID: sid, Intervals: intervals}, nil
+}
+
+func (s *UUIDSet) String() string {
+	if s == nil || len(s.Intervals) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s:%s", s.SID, s.Intervals.String())
+}
+
+func (s *UUIDSet) Clone() *UUIDSet {
+	if s == nil {
+		return nil
+	}
+	intervals := make(IntervalSlice, len(s.Intervals))
+	copy(intervals, s.Intervals)
+	return &UUIDSet{SID: s.SID, Intervals: intervals}
+}
+
+// MysqlGTIDSet is a MySQL GTID set: a map from server UUID to the set of
+// transaction sequence numbers committed by that server.
+//
+// See: https://dev.mysql.com/doc/refman/8.0/en/replication-gtids-concepts.html
+type MysqlGTIDSet struct {
+	Sets map[string]*UUIDSet
+}
+
+// ParseMysqlGTIDSet parses a MySQL GTID set string, e.g.
+// "3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5:11-14,3E11FA47-71CA-11E1-9E33-C80AA9429563:1-5".
+func ParseMysqlGTIDSet(s string) (*MysqlGTIDSet, error) {
+	sets := make(map[string]*UUIDSet)
+	s = strings.TrimSpace(s)
+	if len(s) == 0 {
+		return &MysqlGTIDSet{Sets: sets}, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		us, err := ParseUUIDSet(part)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if existing, ok := sets[us.SID.String()]; ok {
+			existing.Intervals = unionIntervals(existing.Intervals, us.Intervals)
+		} else {
+			sets[us.SID.String()] = us
+		}
+	}
+	return &MysqlGTIDSet{Sets: sets}, nil
+}
+
+func (s *MysqlGTIDSet) String() string {
+	sids := make([]string, 0, len(s.Sets))
+	for _, us := range s.Sets {
+		if len(us.Intervals) == 0 {
+			continue
+		}
+		sids = append(sids, us.String())
+	}
+	sort.Strings(sids)
+	return strings.Join(sids, ",")
+}
+
+func (s *MysqlGTIDSet) Encode() []byte {
+	var buf bytes.Buffer
+
+	var n [8]byte
+	binary.LittleEndian.PutUint64(n[:], uint64(len(s.Sets)))
+	buf.Write(n[:])
+
+	sids := make([]string, 0, len(s.Sets))
+	for sid := range s.Sets {
+		sids = append(sids, sid)
+	}
+	sort.Strings(sids)
+
+	for _, sid := range sids {
+		us := s.Sets[sid]
+		buf.Write(us.SID[:])
+
+		binary.LittleEndian.PutUint64(n[:], uint64(len(us.Intervals)))
+		buf.Write(n[:])
+
+		for _, iv := range us.Intervals {
+			binary.LittleEndian.PutUint64(n[:], uint64(iv.Start))
+			buf.Write(n[:])
+			binary.LittleEndian.PutUint64(n[:], uint64(iv.Stop))
+			buf.Write(n[:])
+		}
+	}
+
+	return buf.Bytes()
+}
+
+func (s *MysqlGTIDSet) Equal(o GTIDSet) bool {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return false
+	}
+	if len(s.Sets) != len(other.Sets) {
+		return false
+	}
+	for sid, us := range s.Sets {
+		ous, ok := other.Sets[sid]
+		if !ok || ous.Intervals.String() != us.Intervals.String() {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MysqlGTIDSet) Contain(o GTIDSet) bool {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return false
+	}
+	for sid, ous := range other.Sets {
+		us, ok := s.Sets[sid]
+		if !ok {
+			if len(ous.Intervals) == 0 {
+				continue
+			}
+			return false
+		}
+		if len(subtractIntervals(ous.Intervals, us.Intervals)) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *MysqlGTIDSet) Update(gtidStr string) error {
+	sets, err := ParseMysqlGTIDSet(gtidStr)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return s.AddSet(sets)
+}
+
+func (s *MysqlGTIDSet) AddSet(o GTIDSet) error {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return errors.Errorf("invalid GTIDSet type %T to merge into MysqlGTIDSet", o)
+	}
+	for sid, ous := range other.Sets {
+		if us, ok := s.Sets[sid]; ok {
+			us.Intervals = unionIntervals(us.Intervals, ous.Intervals)
+		} else {
+			s.Sets[sid] = ous.Clone()
+		}
+	}
+	s.prune()
+	return nil
+}
+
+func (s *MysqlGTIDSet) Union(o GTIDSet) GTIDSet {
+	clone := s.Clone().(*MysqlGTIDSet)
+	// AddSet never fails for a *MysqlGTIDSet argument.
+	_ = clone.AddSet(o)
+	return clone
+}
+
+func (s *MysqlGTIDSet) Intersect(o GTIDSet) GTIDSet {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return &MysqlGTIDSet{Sets: make(map[string]*UUIDSet)}
+	}
+	result := make(map[string]*UUIDSet)
+	for sid, us := range s.Sets {
+		ous, ok := other.Sets[sid]
+		if !ok {
+			continue
+		}
+		intervals := intersectIntervals(us.Intervals, ous.Intervals)
+		if len(intervals) == 0 {
+			continue
+		}
+		result[sid] = &UUIDSet{SID: us.SID, Intervals: intervals}
+	}
+	return &MysqlGTIDSet{Sets: result}
+}
+
+func (s *MysqlGTIDSet) Subtract(o GTIDSet) GTIDSet {
+	other, ok := o.(*MysqlGTIDSet)
+	if !ok {
+		return s.Clone()
+	}
+	result := make(map[string]*UUIDSet)
+	for sid, us := range s.Sets {
+		ous, ok := other.Sets[sid]
+		if !ok {
+			result[sid] = us.Clone()
+			continue
+		}
+		intervals := subtractIntervals(us.Intervals, ous.Intervals)
+		if len(intervals) == 0 {
+			continue
+		}
+		result[sid] = &UUIDSet{SID: us.SID, Intervals: intervals}
+	}
+	return &MysqlGTIDSet{Sets: result}
+}
+
+func (s *MysqlGTIDSet) Clone() GTIDSet {
+	clone := &MysqlGTIDSet{Sets: make(map[string]*UUIDSet, len(s.Sets))}
+	for sid, us := range s.Sets {
+		clone.Sets[sid] = us.Clone()
+	}
+	return clone
+}
+
+func (s *MysqlGTIDSet) IsEmpty() bool {
+	s.prune()
+	return len(s.Sets) == 0
+}
+
+// prune drops every UUIDSet left with no intervals, so IsEmpty and String
+// behave correctly after a Subtract/Intersect narrows a set down to
+// nothing.
+func (s *MysqlGTIDSet) prune() {
+	for sid, us := range s.Sets {
+		if len(us.Intervals) == 0 {
+			delete(s.Sets, sid)
+		}
+	}
+}
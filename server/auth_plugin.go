@@ -0,0 +1,113 @@
+package server
+
+import (
+	"bytes"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+)
+
+// ed25519SaltLen is the scramble length client_ed25519 requires - longer
+// than the 20-byte scramble generated for the initial handshake, so the
+// plugin must hand out its own nonce on AuthSwitchRequest rather than
+// reusing the one Handshake generated.
+const ed25519SaltLen = 32
+
+func init() {
+	RegisterAuthPlugin(nativePasswordAuthPlugin{})
+	RegisterAuthPlugin(clearPasswordAuthPlugin{})
+	RegisterAuthPlugin(cachingSha2PasswordAuthPlugin{})
+	RegisterAuthPlugin(mariadbEd25519AuthPlugin{})
+}
+
+// nativePasswordAuthPlugin implements the server side of mysql_native_password.
+type nativePasswordAuthPlugin struct{}
+
+func (nativePasswordAuthPlugin) Name() string { return mysql.AUTH_NATIVE_PASSWORD }
+
+func (nativePasswordAuthPlugin) AuthSwitchData(salt []byte) []byte { return salt }
+
+func (nativePasswordAuthPlugin) Verify(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	if password == "" {
+		return len(resp) == 0, nil, true, nil
+	}
+	expected := mysql.CalcPassword(salt[:20], []byte(password))
+	return bytes.Equal(expected, resp), nil, true, nil
+}
+
+func (nativePasswordAuthPlugin) Next(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	return false, nil, true, nil
+}
+
+func (nativePasswordAuthPlugin) NeedsTLS() bool { return false }
+
+// clearPasswordAuthPlugin implements the server side of mysql_clear_password.
+type clearPasswordAuthPlugin struct{}
+
+func (clearPasswordAuthPlugin) Name() string { return mysql.AUTH_CLEAR_PASSWORD }
+
+func (clearPasswordAuthPlugin) AuthSwitchData(salt []byte) []byte { return salt }
+
+func (clearPasswordAuthPlugin) Verify(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	return string(resp) == password, nil, true, nil
+}
+
+func (clearPasswordAuthPlugin) Next(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	return false, nil, true, nil
+}
+
+func (clearPasswordAuthPlugin) NeedsTLS() bool { return true }
+
+// cachingSha2PasswordAuthPlugin implements the server side of
+// caching_sha2_password. It does not maintain a fast-auth cache: Verify
+// always checks the client's response as a direct scrambled-password
+// comparison and reports done immediately, so every connection effectively
+// pays caching_sha2_password's "fast auth" cost and none of its "full auth"
+// RSA round trip. A cache (and the AuthMoreData plumbing to drive an actual
+// full-auth round trip on a miss) would need to be layered on top of Verify.
+type cachingSha2PasswordAuthPlugin struct{}
+
+func (cachingSha2PasswordAuthPlugin) Name() string { return mysql.AUTH_CACHING_SHA2_PASSWORD }
+
+func (cachingSha2PasswordAuthPlugin) AuthSwitchData(salt []byte) []byte { return salt }
+
+func (cachingSha2PasswordAuthPlugin) Verify(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	expected := mysql.CalcCachingSha2Password(salt, password)
+	return bytes.Equal(expected, resp), nil, true, nil
+}
+
+func (cachingSha2PasswordAuthPlugin) Next(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	return false, nil, true, nil
+}
+
+func (cachingSha2PasswordAuthPlugin) NeedsTLS() bool { return false }
+
+// mariadbEd25519AuthPlugin implements the server side of MariaDB's
+// client_ed25519 plugin.
+type mariadbEd25519AuthPlugin struct{}
+
+func (mariadbEd25519AuthPlugin) Name() string { return mysql.AUTH_MARIADB_ED25519 }
+
+// AuthSwitchData ignores the handshake's 20-byte scramble and generates its
+// own 32-byte nonce instead - client_ed25519 signs a longer scramble than
+// the one Handshake hands every other plugin, so reusing it would leave
+// Verify always rejecting on the length check below.
+func (mariadbEd25519AuthPlugin) AuthSwitchData(salt []byte) []byte {
+	return mysql.RandomBuf(ed25519SaltLen)
+}
+
+func (mariadbEd25519AuthPlugin) Verify(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	if len(salt) != 32 {
+		return false, nil, true, mysql.ErrMalformPacket
+	}
+	expected, err := mysql.CalcEd25519Password(salt, password)
+	if err != nil {
+		return false, nil, true, err
+	}
+	return bytes.Equal(expected, resp), nil, true, nil
+}
+
+func (mariadbEd25519AuthPlugin) Next(salt []byte, password string, resp []byte) (bool, []byte, bool, error) {
+	return false, nil, true, nil
+}
+
+func (mariadbEd25519AuthPlugin) NeedsTLS() bool { return false }
@@ -0,0 +1,22 @@
+package server
+
+import "testing"
+
+// TestEd25519AuthSwitchDataGeneratesFreshNonce is a regression test for a
+// bug where AuthSwitchData returned the handshake's 20-byte scramble
+// unchanged, so Verify's 32-byte length check rejected every client_ed25519
+// attempt.
+func TestEd25519AuthSwitchDataGeneratesFreshNonce(t *testing.T) {
+	var plugin mariadbEd25519AuthPlugin
+	handshakeSalt := make([]byte, 20)
+
+	switchSalt := plugin.AuthSwitchData(handshakeSalt)
+	if len(switchSalt) != ed25519SaltLen {
+		t.Fatalf("AuthSwitchData returned %d bytes, want %d", len(switchSalt), ed25519SaltLen)
+	}
+
+	other := plugin.AuthSwitchData(handshakeSalt)
+	if string(switchSalt) == string(other) {
+		t.Fatal("AuthSwitchData returned the same nonce twice, expected a fresh random one each call")
+	}
+}
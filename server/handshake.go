@@ -0,0 +1,430 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/packet"
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/pkg/parser/charset"
+)
+
+// CredentialProvider supplies the information needed to verify a client's
+// auth response during the connection phase, keyed by username. Programs
+// accepting real MySQL clients (proxies, routers, query rewriters) implement
+// this to plug in their own user store.
+type CredentialProvider interface {
+	// CheckUsername reports whether username is known to this provider.
+	// A false return short-circuits the handshake with an access-denied
+	// error before any auth plugin runs.
+	CheckUsername(username string) (bool, error)
+
+	// GetCredential returns the plaintext password for username, for
+	// plugins that need it to verify a scrambled response
+	// (mysql_native_password, caching_sha2_password, ...).
+	GetCredential(username string) (password string, found bool, err error)
+}
+
+// AuthPlugin is the server-side half of a MySQL/MariaDB authentication
+// method, the counterpart of client.AuthPlugin. Built-ins (mysql_native_password,
+// caching_sha2_password, mysql_clear_password, client_ed25519) are registered
+// by this package's init(); a program can add its own (e.g. to accept
+// caching_sha2_password without TLS by always serving a pinned RSA key) with
+// RegisterAuthPlugin.
+type AuthPlugin interface {
+	// Name is the plugin name as advertised in the initial handshake /
+	// AuthSwitchRequest packet, e.g. "caching_sha2_password".
+	Name() string
+
+	// AuthSwitchData returns the auth-plugin-data this plugin wants the
+	// client to use, given a scramble Handshake generated - typically that
+	// scramble itself. Handshake sends it in the initial handshake for the
+	// offered plugin, and calls it again for the AuthSwitchRequest when the
+	// client switches to another plugin.
+	AuthSwitchData(salt []byte) []byte
+
+	// Verify checks the client's auth response against password, given
+	// the salt sent in AuthSwitchData. done is false if the plugin needs
+	// another round (e.g. caching_sha2_password full authentication);
+	// next is then the next auth-method-data packet to send the client,
+	// whose reply comes back through Next.
+	Verify(salt []byte, password string, resp []byte) (ok bool, next []byte, done bool, err error)
+
+	// Next verifies the client's reply to a Verify-issued next packet.
+	Next(salt []byte, password string, resp []byte) (ok bool, next []byte, done bool, err error)
+
+	// NeedsTLS reports whether this plugin requires an encrypted or unix
+	// socket channel to be safe to serve, e.g. mysql_clear_password.
+	NeedsTLS() bool
+}
+
+var authPluginRegistry = map[string]AuthPlugin{}
+
+// RegisterAuthPlugin adds p to the set of auth plugins this server can
+// offer clients, keyed by p.Name(). Registering a name that already exists
+// replaces the previous plugin, so callers may override a built-in.
+func RegisterAuthPlugin(p AuthPlugin) {
+	authPluginRegistry[p.Name()] = p
+}
+
+func getAuthPlugin(name string) (AuthPlugin, bool) {
+	p, ok := authPluginRegistry[name]
+	return p, ok
+}
+
+// HandshakeConfig holds the parameters a Server negotiates with a client
+// during the connection phase.
+type HandshakeConfig struct {
+	// ConnectionID is reported to the client in the initial handshake
+	// packet and echoed back by COM_PROCESS_KILL.
+	ConnectionID uint32
+
+	// ServerVersion is reported to the client in the initial handshake
+	// packet, e.g. "8.0.30-go-mysql".
+	ServerVersion string
+
+	// Collation is the server default collation, used when the client
+	// does not request one explicitly.
+	Collation string
+
+	// AuthPluginName selects which registered AuthPlugin is offered
+	// first; it defaults to mysql_native_password.
+	AuthPluginName string
+
+	// TLSConfig, if non-nil, is offered to the client as CLIENT_SSL and
+	// used to upgrade the connection when the client requests it.
+	TLSConfig *tls.Config
+
+	// Credentials resolves usernames to passwords for auth plugins that
+	// need them.
+	Credentials CredentialProvider
+
+	// ZstdCompressionLevel, if non-zero, is negotiated with clients that
+	// advertise CLIENT_ZSTD_COMPRESSION_ALGORITHM.
+	ZstdCompressionLevel int
+
+	// DisallowPluginSwitch, if true, rejects a client that proposes a
+	// different auth plugin than AuthPluginName via AuthSwitchRequest
+	// instead of switching to it. It defaults to false, so a client is
+	// free to negotiate any plugin this server has registered unless an
+	// operator opts into pinning everyone to AuthPluginName.
+	DisallowPluginSwitch bool
+}
+
+// HandshakeResult captures what was negotiated with a client once the
+// connection phase has completed successfully.
+type HandshakeResult struct {
+	Capability   uint32
+	Collation    uint8
+	User         string
+	DB           string
+	AuthPlugin   string
+	Attributes   map[string]string
+	ZstdLevel    int
+	TLSUpgraded  bool
+}
+
+// Handshake runs the server side of the MySQL connection phase over conn:
+// it sends the initial handshake packet, negotiates capabilities and TLS,
+// runs the configured auth plugin (switching plugins via AuthSwitchRequest
+// if the client's chosen plugin differs), and returns what was negotiated.
+// conn is replaced in place if the client upgrades to TLS.
+func Handshake(conn *packet.Conn, cfg HandshakeConfig) (*HandshakeResult, error) {
+	pluginName := cfg.AuthPluginName
+	if pluginName == "" {
+		pluginName = mysql.AUTH_NATIVE_PASSWORD
+	}
+	plugin, ok := getAuthPlugin(pluginName)
+	if !ok {
+		return nil, fmt.Errorf("auth plugin '%s' is not registered", pluginName)
+	}
+
+	salt := plugin.AuthSwitchData(mysql.RandomBuf(20))
+
+	serverCapability := mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_SECURE_CONNECTION |
+		mysql.CLIENT_LONG_PASSWORD | mysql.CLIENT_TRANSACTIONS | mysql.CLIENT_PLUGIN_AUTH |
+		mysql.CLIENT_CONNECT_ATTRS | mysql.CLIENT_CONNECT_WITH_DB | mysql.CLIENT_LONG_FLAG
+	if cfg.TLSConfig != nil {
+		serverCapability |= mysql.CLIENT_SSL
+	}
+	if cfg.ZstdCompressionLevel > 0 {
+		serverCapability |= mysql.CLIENT_ZSTD_COMPRESSION_ALGORITHM
+	}
+
+	if err := writeInitialHandshake(conn, cfg, serverCapability, salt, pluginName); err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	resp, err := readHandshakeResponse(conn, cfg, serverCapability)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	if plugin.NeedsTLS() && !resp.tlsUpgraded {
+		return nil, fmt.Errorf("auth plugin '%s' requires TLS, but the client did not upgrade the connection", pluginName)
+	}
+
+	if cfg.Credentials != nil {
+		known, err := cfg.Credentials.CheckUsername(resp.user)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		if !known {
+			return nil, errors.Errorf("access denied for user '%s'", resp.user)
+		}
+	}
+
+	// switch plugins if the client proposed a different one than we
+	// offered in the initial handshake
+	authData := resp.authData
+	if resp.authPluginName != "" && resp.authPluginName != pluginName {
+		if cfg.DisallowPluginSwitch {
+			return nil, fmt.Errorf("client requested auth plugin '%s', but this server is pinned to '%s'", resp.authPluginName, pluginName)
+		}
+		p, ok := getAuthPlugin(resp.authPluginName)
+		if !ok {
+			return nil, fmt.Errorf("auth plugin '%s' is not registered", resp.authPluginName)
+		}
+		plugin = p
+		pluginName = resp.authPluginName
+		if plugin.NeedsTLS() && !resp.tlsUpgraded {
+			return nil, fmt.Errorf("auth plugin '%s' requires TLS, but the client did not upgrade the connection", pluginName)
+		}
+		switchSalt := plugin.AuthSwitchData(salt)
+		if err := writeAuthSwitchRequest(conn, pluginName, switchSalt); err != nil {
+			return nil, errors.Trace(err)
+		}
+		authData, err = conn.ReadPacket()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		salt = switchSalt
+	}
+
+	var password string
+	if cfg.Credentials != nil {
+		password, _, err = cfg.Credentials.GetCredential(resp.user)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+
+	ok, next, done, err := plugin.Verify(salt, password, authData)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	for !done {
+		if !ok {
+			return nil, fmt.Errorf("access denied for user '%s' (using %s)", resp.user, pluginName)
+		}
+		if err := conn.WritePacket(next); err != nil {
+			return nil, errors.Trace(err)
+		}
+		reply, err := conn.ReadPacket()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		ok, next, done, err = plugin.Next(salt, password, reply)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("access denied for user '%s' (using %s)", resp.user, pluginName)
+	}
+
+	return &HandshakeResult{
+		Capability:  resp.capability,
+		Collation:   resp.collation,
+		User:        resp.user,
+		DB:          resp.db,
+		AuthPlugin:  pluginName,
+		Attributes:  resp.attributes,
+		ZstdLevel:   resp.zstdLevel,
+		TLSUpgraded: resp.tlsUpgraded,
+	}, nil
+}
+
+// writeInitialHandshake sends the HandshakeV10 packet, mirroring the layout
+// client.Conn.readInitialHandshake parses.
+//
+// See: https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_connection_phase_packets_protocol_handshake_v10.html
+func writeInitialHandshake(conn *packet.Conn, cfg HandshakeConfig, capability uint32, salt []byte, pluginName string) error {
+	version := cfg.ServerVersion
+	if version == "" {
+		version = "8.0.11-go-mysql"
+	}
+
+	collation, err := charset.GetCollationByName(defaultString(cfg.Collation, mysql.DEFAULT_COLLATION_NAME))
+	if err != nil {
+		return fmt.Errorf("invalid collation name %s", cfg.Collation)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(mysql.ClassicProtocolVersion)
+	buf.WriteString(version)
+	buf.WriteByte(0x00)
+
+	var connID [4]byte
+	binary.LittleEndian.PutUint32(connID[:], cfg.ConnectionID)
+	buf.Write(connID[:])
+
+	buf.Write(salt[:8])
+	buf.WriteByte(0x00)
+
+	buf.WriteByte(byte(capability))
+	buf.WriteByte(byte(capability >> 8))
+
+	buf.WriteByte(byte(collation.ID & 0xff))
+
+	var status [2]byte
+	binary.LittleEndian.PutUint16(status[:], mysql.SERVER_STATUS_AUTOCOMMIT)
+	buf.Write(status[:])
+
+	buf.WriteByte(byte(capability >> 16))
+	buf.WriteByte(byte(capability >> 24))
+
+	buf.WriteByte(byte(len(salt) + 1))
+	buf.Write(make([]byte, 10))
+
+	buf.Write(salt[8:])
+	buf.WriteByte(0x00)
+
+	buf.WriteString(pluginName)
+	buf.WriteByte(0x00)
+
+	return conn.WritePacket(buf.Bytes())
+}
+
+func writeAuthSwitchRequest(conn *packet.Conn, pluginName string, salt []byte) error {
+	var buf bytes.Buffer
+	buf.WriteByte(mysql.AuthSwitchRequestHeader)
+	buf.WriteString(pluginName)
+	buf.WriteByte(0x00)
+	buf.Write(salt)
+	buf.WriteByte(0x00)
+	return conn.WritePacket(buf.Bytes())
+}
+
+type handshakeResponse struct {
+	capability     uint32
+	collation      uint8
+	user           string
+	db             string
+	authData       []byte
+	authPluginName string
+	attributes     map[string]string
+	zstdLevel      int
+	tlsUpgraded    bool
+}
+
+// readHandshakeResponse parses a HandshakeResponse41 packet, upgrading conn
+// to TLS first if the client sent an SSLRequest.
+//
+// See: http://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeResponse
+func readHandshakeResponse(conn *packet.Conn, cfg HandshakeConfig, serverCapability uint32) (*handshakeResponse, error) {
+	data, err := conn.ReadPacket()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+
+	capability := binary.LittleEndian.Uint32(data[:4])
+	collation := data[8]
+	pos := 32
+
+	tlsUpgraded := false
+	if capability&mysql.CLIENT_SSL != 0 && cfg.TLSConfig != nil {
+		tlsConn := tls.Server(conn.Conn, cfg.TLSConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, errors.Trace(err)
+		}
+		seq := conn.Sequence
+		*conn = *packet.NewConnWithTimeout(tlsConn, conn.ReadTimeout, conn.WriteTimeout, conn.BufferSize)
+		conn.Sequence = seq
+		tlsUpgraded = true
+
+		data, err = conn.ReadPacket()
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		capability = binary.LittleEndian.Uint32(data[:4])
+		collation = data[8]
+		pos = 32
+	}
+
+	user := string(data[pos : pos+bytes.IndexByte(data[pos:], 0x00)])
+	pos += len(user) + 1
+
+	var authData []byte
+	if capability&mysql.CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA != 0 {
+		num, _, n := mysql.LengthEncodedInt(data[pos:])
+		pos += n
+		authData = data[pos : pos+int(num)]
+		pos += int(num)
+	} else if capability&mysql.CLIENT_SECURE_CONNECTION != 0 {
+		authLen := int(data[pos])
+		pos++
+		authData = data[pos : pos+authLen]
+		pos += authLen
+	} else {
+		end := bytes.IndexByte(data[pos:], 0x00)
+		authData = data[pos : pos+end]
+		pos += end + 1
+	}
+
+	var db string
+	if capability&mysql.CLIENT_CONNECT_WITH_DB != 0 && pos < len(data) {
+		end := bytes.IndexByte(data[pos:], 0x00)
+		db = string(data[pos : pos+end])
+		pos += end + 1
+	}
+
+	var authPluginName string
+	if capability&mysql.CLIENT_PLUGIN_AUTH != 0 && pos < len(data) {
+		end := bytes.IndexByte(data[pos:], 0x00)
+		authPluginName = string(data[pos : pos+end])
+		pos += end + 1
+	}
+
+	attributes := make(map[string]string)
+	if capability&mysql.CLIENT_CONNECT_ATTRS != 0 && pos < len(data) {
+		attrLen, _, n := mysql.LengthEncodedInt(data[pos:])
+		pos += n
+		end := pos + int(attrLen)
+		for pos < end {
+			key, _, n, _ := mysql.LengthEncodedString(data[pos:])
+			pos += n
+			val, _, n, _ := mysql.LengthEncodedString(data[pos:])
+			pos += n
+			attributes[string(key)] = string(val)
+		}
+	}
+
+	zstdLevel := 0
+	if capability&mysql.CLIENT_ZSTD_COMPRESSION_ALGORITHM != 0 && pos < len(data) {
+		zstdLevel = int(data[pos])
+		pos++
+	}
+
+	return &handshakeResponse{
+		capability:     capability & serverCapability,
+		collation:      collation,
+		user:           user,
+		db:             db,
+		authData:       authData,
+		authPluginName: authPluginName,
+		attributes:     attributes,
+		zstdLevel:      zstdLevel,
+		tlsUpgraded:    tlsUpgraded,
+	}, nil
+}
+
+func defaultString(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/packet"
+)
+
+// buildHandshakeResponse crafts a minimal HandshakeResponse41 packet: no
+// SSL request, no DB, CLIENT_SECURE_CONNECTION-style (non-lenenc) auth data,
+// proposing authPluginName as the client's auth plugin.
+func buildHandshakeResponse(user, authPluginName string, authData []byte) []byte {
+	capability := mysql.CLIENT_PROTOCOL_41 | mysql.CLIENT_SECURE_CONNECTION | mysql.CLIENT_PLUGIN_AUTH
+
+	buf := make([]byte, 32)
+	binary.LittleEndian.PutUint32(buf[:4], uint32(capability))
+	buf[8] = 0 // collation, unused by these tests
+
+	buf = append(buf, []byte(user)...)
+	buf = append(buf, 0x00)
+
+	buf = append(buf, byte(len(authData)))
+	buf = append(buf, authData...)
+
+	buf = append(buf, []byte(authPluginName)...)
+	buf = append(buf, 0x00)
+
+	return buf
+}
+
+type staticCredentials struct {
+	user     string
+	password string
+}
+
+func (s staticCredentials) CheckUsername(username string) (bool, error) {
+	return username == s.user, nil
+}
+
+func (s staticCredentials) GetCredential(username string) (string, bool, error) {
+	if username != s.user {
+		return "", false, nil
+	}
+	return s.password, true, nil
+}
+
+// TestHandshakeDisallowPluginSwitchRejectsSwitch is a regression test for
+// the plugin switch having no policy enforcement beyond NeedsTLS: a client
+// proposing a different auth plugin than cfg.AuthPluginName must be
+// rejected outright once DisallowPluginSwitch is set.
+func TestHandshakeDisallowPluginSwitchRejectsSwitch(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := HandshakeConfig{
+		AuthPluginName:       mysql.AUTH_NATIVE_PASSWORD,
+		Credentials:          staticCredentials{user: "root", password: ""},
+		DisallowPluginSwitch: true,
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		_, err := Handshake(packet.NewConnWithTimeout(serverSide, 0, 0, 0), cfg)
+		result <- err
+	}()
+
+	client := packet.NewConnWithTimeout(clientSide, 0, 0, 0)
+	if _, err := client.ReadPacket(); err != nil {
+		t.Fatalf("read initial handshake: %v", err)
+	}
+
+	// propose clear_password instead of the offered native password
+	resp := buildHandshakeResponse("root", mysql.AUTH_CLEAR_PASSWORD, nil)
+	if err := client.WritePacket(resp); err != nil {
+		t.Fatalf("write handshake response: %v", err)
+	}
+
+	err := <-result
+	if err == nil {
+		t.Fatal("expected Handshake to reject the plugin switch, got nil error")
+	}
+}
+
+// TestHandshakeAllowsSwitchByDefault confirms DisallowPluginSwitch's zero
+// value keeps the previous permissive behavior: a client offering no
+// password may still switch to a different registered plugin.
+func TestHandshakeAllowsSwitchByDefault(t *testing.T) {
+	serverSide, clientSide := net.Pipe()
+	defer clientSide.Close()
+
+	cfg := HandshakeConfig{
+		AuthPluginName: mysql.AUTH_CLEAR_PASSWORD,
+		Credentials:    staticCredentials{user: "root", password: ""},
+	}
+
+	result := make(chan *HandshakeResult, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		r, err := Handshake(packet.NewConnWithTimeout(serverSide, 0, 0, 0), cfg)
+		result <- r
+		errCh <- err
+	}()
+
+	client := packet.NewConnWithTimeout(clientSide, 0, 0, 0)
+	if _, err := client.ReadPacket(); err != nil {
+		t.Fatalf("read initial handshake: %v", err)
+	}
+
+	// switch to native password, which needs no TLS and accepts an empty
+	// response for an empty password
+	resp := buildHandshakeResponse("root", mysql.AUTH_NATIVE_PASSWORD, nil)
+	if err := client.WritePacket(resp); err != nil {
+		t.Fatalf("write handshake response: %v", err)
+	}
+
+	if err := <-errCh; err != nil {
+		t.Fatalf("Handshake returned error: %v", err)
+	}
+	r := <-result
+	if r.AuthPlugin != mysql.AUTH_NATIVE_PASSWORD {
+		t.Fatalf("AuthPlugin = %q, want %q", r.AuthPlugin, mysql.AUTH_NATIVE_PASSWORD)
+	}
+}